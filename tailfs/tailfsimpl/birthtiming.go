@@ -42,6 +42,8 @@ type birthTimingFileInfo struct {
 	os.FileInfo
 }
 
+// BirthTime implements webdav.BirthTimer. See shared.StaticFileInfo.BirthTime
+// regarding how xnet/webdav formats the resulting creationdate.
 func (fi *birthTimingFileInfo) BirthTime(ctx context.Context) (time.Time, error) {
 	if fi.Sys() == nil {
 		return time.Time{}, webdav.ErrNotImplemented
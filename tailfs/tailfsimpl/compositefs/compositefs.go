@@ -13,6 +13,7 @@
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/tailscale/xnet/webdav"
 	"tailscale.com/tailfs/tailfsimpl/shared"
@@ -87,22 +88,55 @@ type CompositeFileSystem struct {
 	statChildren bool
 	now          func() time.Time
 
-	// childrenMu guards children
+	// childrenMu guards children. children is never modified in place, only
+	// replaced, so it's safe to keep using a snapshot of it after releasing
+	// childrenMu. This lets root listings avoid copying the children.
 	childrenMu sync.Mutex
 	children   []*Child
 }
 
+// maxChildNameLength is the maximum length of a Child's name in bytes. This
+// matches the filename length limit of most common filesystems.
+const maxChildNameLength = 255
+
+// validChildName reports whether name can be used as the name of a Child.
+// Each child appears as a single path component within the root, so its name
+// must survive path cleaning unchanged (which rules out empty names, path
+// separators and leading or trailing dots), must not contain control
+// characters and must not be "*", which clients use to address the server as a
+// whole (e.g. OPTIONS *).
+func validChildName(name string) bool {
+	if name == "" || name == "*" || len(name) > maxChildNameLength {
+		return false
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return false
+	}
+	parts := shared.CleanAndSplit(name)
+	return len(parts) == 1 && parts[0] == name
+}
+
 // AddChild ads a single child with the given name, replacing any existing
-// child with the same name.
+// child with the same name. Children with invalid names (see validChildName)
+// are logged, closed and ignored rather than reported as an error, since
+// children are typically derived from the netmap and a single bad name
+// shouldn't affect the rest.
 func (cfs *CompositeFileSystem) AddChild(child *Child) {
+	if !validChildName(child.Name) {
+		cfs.rejectChild(child)
+		return
+	}
+
 	cfs.childrenMu.Lock()
 	oldIdx, oldChild := cfs.findChildLocked(child.Name)
 	if oldChild != nil {
 		// replace old child
-		cfs.children[oldIdx] = child
+		children := slices.Clone(cfs.children)
+		children[oldIdx] = child
+		cfs.children = children
 	} else {
-		// insert new child
-		cfs.children = slices.Insert(cfs.children, oldIdx, child)
+		// insert new child, clipping to make sure we get a new backing array
+		cfs.children = slices.Insert(slices.Clip(cfs.children), oldIdx, child)
 	}
 	cfs.childrenMu.Unlock()
 
@@ -121,8 +155,7 @@ func (cfs *CompositeFileSystem) RemoveChild(name string) {
 	oldPos, oldChild := cfs.findChildLocked(name)
 	if oldChild != nil {
 		// remove old child
-		copy(cfs.children[oldPos:], cfs.children[oldPos+1:])
-		cfs.children = cfs.children[:len(cfs.children)-1]
+		cfs.children = slices.Concat(cfs.children[:oldPos], cfs.children[oldPos+1:])
 	}
 	cfs.childrenMu.Unlock()
 
@@ -138,8 +171,16 @@ func (cfs *CompositeFileSystem) RemoveChild(name string) {
 }
 
 // SetChildren replaces the entire existing set of children with the given
-// ones.
+// ones. As with AddChild, children with invalid names are logged, closed and
+// ignored rather than reported as an error.
 func (cfs *CompositeFileSystem) SetChildren(children ...*Child) {
+	children = slices.DeleteFunc(slices.Clone(children), func(child *Child) bool {
+		if validChildName(child.Name) {
+			return false
+		}
+		cfs.rejectChild(child)
+		return true
+	})
 	slices.SortFunc(children, func(a, b *Child) int {
 		return strings.Compare(a.Name, b.Name)
 	})
@@ -157,10 +198,23 @@ func (cfs *CompositeFileSystem) SetChildren(children ...*Child) {
 	}
 }
 
+// rejectChild logs that the given child is being ignored because of its
+// invalid name and closes it, since ownership of children passes to cfs.
+func (cfs *CompositeFileSystem) rejectChild(child *Child) {
+	cfs.logf("ignoring child with invalid name %q", child.Name)
+	if closer, ok := child.FS.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			cfs.logf("closing child filesystem %q: %v", child.Name, err)
+		}
+	}
+}
+
 // GetChild returns the child with the given name and a boolean indicating
 // whether or not it was found.
 func (cfs *CompositeFileSystem) GetChild(name string) (webdav.FileSystem, bool) {
+	cfs.childrenMu.Lock()
 	_, child := cfs.findChildLocked(name)
+	cfs.childrenMu.Unlock()
 	if child == nil {
 		return nil, false
 	}
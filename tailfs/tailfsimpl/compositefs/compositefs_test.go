@@ -6,16 +6,23 @@
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/tailscale/xnet/webdav"
 	"tailscale.com/tailfs/tailfsimpl/shared"
+	"tailscale.com/tailfs/tailfsimpl/webdavfs"
 	"tailscale.com/tstest"
 )
 
@@ -377,6 +384,416 @@ func TestRename(t *testing.T) {
 	}
 }
 
+// TestConcurrentChildUpdates makes sure that root listings can safely use
+// their snapshot of the children while children are being added and removed.
+// Run with -race.
+func TestConcurrentChildUpdates(t *testing.T) {
+	dir := t.TempDir()
+	cfs := New(Options{Logf: t.Logf})
+	children := make([]*Child, 0, 10)
+	for i := range 10 {
+		children = append(children, &Child{Name: fmt.Sprintf("remote%d", i), FS: webdav.Dir(dir)})
+	}
+	cfs.SetChildren(children...)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			name := fmt.Sprintf("remote%d", i%10)
+			cfs.RemoveChild(name)
+			cfs.AddChild(&Child{Name: name, FS: webdav.Dir(dir)})
+		}
+	}()
+
+	ctx := context.Background()
+	for range 100 {
+		f, err := cfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Readdir(0); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		cfs.GetChild("remote0")
+	}
+	<-done
+}
+
+func TestConcurrentSetChildren(t *testing.T) {
+	// Make sure superseded children are closed and don't leak goroutines
+	tstest.ResourceCheck(t)
+
+	cfs := New(Options{Logf: t.Logf})
+	defer cfs.Close()
+
+	const numSets = 10
+	var wg sync.WaitGroup
+	for i := range numSets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			children := make([]*Child, 0, 3)
+			for j := range 3 {
+				children = append(children, newClosableChild(t, fmt.Sprintf("set%d-remote%d", i, 2-j)))
+			}
+			cfs.SetChildren(children...)
+		}()
+	}
+	wg.Wait()
+
+	f, err := cfs.OpenFile(context.Background(), "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	infos, err := f.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("got %d children, want 3", len(infos))
+	}
+	set, _, _ := strings.Cut(infos[0].Name(), "-")
+	for j, fi := range infos {
+		if want := fmt.Sprintf("%s-remote%d", set, j); fi.Name() != want {
+			t.Errorf("got child %q at %d, want %q", fi.Name(), j, want)
+		}
+	}
+}
+
+func BenchmarkRootListing(b *testing.B) {
+	dir := b.TempDir()
+	cfs := New(Options{Logf: b.Logf})
+	children := make([]*Child, 0, 500)
+	for i := range 500 {
+		children = append(children, &Child{Name: fmt.Sprintf("remote%d", i), FS: webdav.Dir(dir)})
+	}
+	cfs.SetChildren(children...)
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		f, err := cfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		infos, err := f.Readdir(0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(infos) != len(children) {
+			b.Fatalf("got %d children, want %d", len(infos), len(children))
+		}
+		f.Close()
+	}
+}
+
+func TestCopyAcrossChildren(t *testing.T) {
+	cfs, dir1, dir2, _, close := createFileSystem(t, nil)
+	defer close()
+
+	err := os.Mkdir(filepath.Join(dir1, "tocopy"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir1, "tocopy", "nested.txt"), []byte("nested"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHandler(cfs)
+
+	tests := []struct {
+		label      string
+		depth      string
+		dest       string
+		wantNested bool
+	}{
+		{
+			label: "depth 0 copies only the collection",
+			depth: "0",
+			dest:  "copy0",
+		},
+		{
+			label:      "depth infinity copies recursively",
+			depth:      "infinity",
+			dest:       "copyinfinity",
+			wantNested: true,
+		},
+		{
+			label:      "no depth acts as infinity",
+			dest:       "copydefault",
+			wantNested: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			headers := map[string]string{"Destination": "/remote2/" + test.dest}
+			if test.depth != "" {
+				headers["Depth"] = test.depth
+			}
+			rec := serve(h, "COPY", "/remote1/tocopy", nil, headers)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusCreated)
+			}
+
+			if !stat(t, filepath.Join(dir2, test.dest)).IsDir() {
+				t.Error("copied collection should be a directory")
+			}
+			_, err := os.Stat(filepath.Join(dir2, test.dest, "nested.txt"))
+			if test.wantNested && err != nil {
+				t.Errorf("nested file should have been copied: %v", err)
+			} else if !test.wantNested && !os.IsNotExist(err) {
+				t.Errorf("nested file should not have been copied: %v", err)
+			}
+		})
+	}
+}
+
+func TestPropfindWithoutBody(t *testing.T) {
+	cfs, _, _, _, close := createFileSystem(t, nil)
+	defer close()
+
+	// An empty PROPFIND body means allprop, and a missing Depth header means
+	// infinity.
+	rec := serve(newHandler(cfs), "PROPFIND", "/", nil, nil)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<D:href>/</D:href>",
+		"<D:href>/remote1/</D:href>",
+		"<D:href>/remote1/file1.txt</D:href>",
+		"<D:href>/remote2/</D:href>",
+		"<D:resourcetype>",
+		"<D:getlastmodified>",
+		"<D:getcontentlength>5</D:getcontentlength>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCopyToEncodedDestination(t *testing.T) {
+	cfs, _, dir2, _, close := createFileSystem(t, nil)
+	defer close()
+
+	rec := serve(newHandler(cfs), "COPY", "/remote1/file1.txt", nil, map[string]string{
+		"Destination": "http://example.com/remote2/a%20b%23c.txt",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusCreated)
+	}
+	b, err := os.ReadFile(filepath.Join(dir2, "a b#c.txt"))
+	if err != nil {
+		t.Fatalf("copy not found at decoded destination: %v", err)
+	}
+	if got, want := string(b), "12345"; got != want {
+		t.Errorf("got contents %q, want %q", got, want)
+	}
+}
+
+func TestProppatchDisplayName(t *testing.T) {
+	cfs, _, _, _, close := createFileSystem(t, nil)
+	defer close()
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:">
+  <D:set><D:prop><D:displayname>renamed</D:displayname></D:prop></D:set>
+</D:propertyupdate>`
+	rec := serve(newHandler(cfs), "PROPPATCH", "/remote1", strings.NewReader(body), nil)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+	for _, want := range []string{
+		"<D:status>HTTP/1.1 403 Forbidden</D:status>",
+		"<D:cannot-modify-protected-property",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("response missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestNoChildren(t *testing.T) {
+	cfs := New(Options{Logf: t.Logf})
+	h := newHandler(cfs)
+
+	t.Run("root listing", func(t *testing.T) {
+		rec := serve(h, "PROPFIND", "/", nil, map[string]string{"Depth": "1"})
+		if rec.Code != http.StatusMultiStatus {
+			t.Fatalf("got status %d, want %d", rec.Code, http.StatusMultiStatus)
+		}
+		if got := strings.Count(rec.Body.String(), "<D:response>"); got != 1 {
+			t.Errorf("got %d responses, want only the root:\n%s", got, rec.Body.String())
+		}
+	})
+
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{method: "PROPFIND", wantStatus: http.StatusNotFound},
+		{method: "GET", wantStatus: http.StatusNotFound},
+		{method: "PUT", wantStatus: http.StatusNotFound},
+		// RFC 4918 requires 409 when the parent collection is missing.
+		{method: "MKCOL", wantStatus: http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" in missing child", func(t *testing.T) {
+			rec := serve(h, test.method, "/remote1/file.txt", nil, nil)
+			if rec.Code != test.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestInvalidChildNames(t *testing.T) {
+	dir := t.TempDir()
+	cfs := New(Options{Logf: t.Logf})
+
+	invalidNames := []string{
+		"",
+		".",
+		"..",
+		"a/b",
+		"/a",
+		"a\x00b",
+		"a\nb",
+		".hidden",
+		"trail.",
+		"*",
+		strings.Repeat("a", maxChildNameLength+1),
+	}
+	children := []*Child{{Name: "valid", FS: webdav.Dir(dir)}}
+	for _, name := range invalidNames {
+		children = append(children, &Child{Name: name, FS: webdav.Dir(dir)})
+	}
+	cfs.SetChildren(children...)
+	for _, name := range invalidNames {
+		cfs.AddChild(&Child{Name: name, FS: webdav.Dir(dir)})
+	}
+	cfs.AddChild(&Child{Name: "also valid", FS: webdav.Dir(dir)})
+
+	for _, name := range invalidNames {
+		if _, ok := cfs.GetChild(name); ok {
+			t.Errorf("child with invalid name %q should have been ignored", name)
+		}
+	}
+
+	f, err := cfs.OpenFile(context.Background(), "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	infos, err := f.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fi := range infos {
+		got = append(got, fi.Name())
+	}
+	want := []string{"also valid", "valid"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got children %q, want %q", got, want)
+	}
+
+	// accepted children must be routable
+	for _, name := range want {
+		if _, err := cfs.Stat(context.Background(), "/"+name); err != nil {
+			t.Errorf("unable to stat child %q: %v", name, err)
+		}
+	}
+}
+
+func TestInvalidChildrenClosed(t *testing.T) {
+	// Make sure rejected children are closed and don't leak goroutines
+	tstest.ResourceCheck(t)
+
+	cfs := New(Options{Logf: t.Logf})
+	defer cfs.Close()
+
+	for range 10 {
+		cfs.SetChildren(newClosableChild(t, "valid"), newClosableChild(t, ".bad"))
+		cfs.AddChild(newClosableChild(t, "trail."))
+	}
+}
+
+func TestPathNormalization(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "dir1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	child := &recordingFS{FileSystem: webdav.Dir(dir)}
+	cfs := New(Options{Logf: t.Logf})
+	cfs.SetChildren(&Child{Name: "remote1", FS: child})
+
+	ctx := context.Background()
+	for _, name := range []string{
+		"/remote1/dir1",
+		"/remote1//dir1",
+		"//remote1/dir1",
+		"/remote1/./dir1",
+		"/remote1/dir1/.",
+		"/remote1/dir1/",
+		"/remote1/dir2/../dir1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := cfs.Stat(ctx, name); err != nil {
+				t.Fatalf("unable to stat: %v", err)
+			}
+			if got, want := child.lastStat, "dir1"; got != want {
+				t.Errorf("child got path %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestModTimeFormat(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	want := "<D:getlastmodified>Tue, 02 Jan 2024 15:04:05 GMT</D:getlastmodified>"
+	for _, zone := range []*time.Location{
+		time.UTC,
+		time.FixedZone("UTC+9", 9*60*60),
+		time.FixedZone("UTC-7", -7*60*60),
+	} {
+		t.Run(zone.String(), func(t *testing.T) {
+			clock := tstest.NewClock(tstest.ClockOpts{Start: start.In(zone)})
+			cfs := New(Options{Logf: t.Logf, Clock: clock})
+			dir := t.TempDir()
+			file := filepath.Join(dir, "file.txt")
+			if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, start.In(zone), start.In(zone)); err != nil {
+				t.Fatal(err)
+			}
+			cfs.SetChildren(&Child{Name: "remote1", FS: webdav.Dir(dir)})
+
+			// The root's time comes from the clock, the file's from the child.
+			h := newHandler(cfs)
+			for _, name := range []string{"/", "/remote1/file.txt"} {
+				rec := serve(h, "PROPFIND", name, nil, map[string]string{"Depth": "0"})
+				if rec.Code != http.StatusMultiStatus {
+					t.Fatalf("%v: got status %d, want %d", name, rec.Code, http.StatusMultiStatus)
+				}
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("%v: response missing %q:\n%s", name, want, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
 func createFileSystem(t *testing.T, opts *Options) (webdav.FileSystem, string, string, *tstest.Clock, func()) {
 	l1, dir1 := startRemote(t)
 	l2, dir2 := startRemote(t)
@@ -444,6 +861,41 @@ func createFileSystem(t *testing.T, opts *Options) (webdav.FileSystem, string, s
 	}
 }
 
+// newHandler returns a webdav.Handler that serves fs using an in-memory lock
+// system.
+func newHandler(fs webdav.FileSystem) *webdav.Handler {
+	return &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// serve sends a request with the given method, target, body and headers to h
+// and returns the recorded response.
+func serve(h http.Handler, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// newClosableChild returns a child with the given name backed by a webdavfs
+// whose StatCache runs a goroutine until the filesystem is closed. Its URL
+// isn't expected to be reachable.
+func newClosableChild(t *testing.T, name string) *Child {
+	return &Child{
+		Name: name,
+		FS: webdavfs.New(webdavfs.Options{
+			Logf:         t.Logf,
+			URL:          "http://127.0.0.1:1",
+			StatCacheTTL: time.Minute,
+		}),
+	}
+}
+
 func stat(t *testing.T, path string) fs.FileInfo {
 	fi, err := os.Stat(path)
 	if err != nil {
@@ -495,3 +947,15 @@ type closeableFS struct {
 func (cfs *closeableFS) Close() error {
 	return nil
 }
+
+// recordingFS is a webdav.FileSystem that records the name passed to its most
+// recent call to Stat.
+type recordingFS struct {
+	webdav.FileSystem
+	lastStat string
+}
+
+func (rfs *recordingFS) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	rfs.lastStat = name
+	return rfs.FileSystem.Stat(ctx, name)
+}
@@ -41,7 +41,7 @@ func (cfs *CompositeFileSystem) OpenFile(ctx context.Context, name string, flag
 			children := cfs.children
 			cfs.childrenMu.Unlock()
 
-			childInfos := make([]fs.FileInfo, 0, len(cfs.children))
+			childInfos := make([]fs.FileInfo, 0, len(children))
 			for _, c := range children {
 				if c.isAvailable() {
 					var childInfo fs.FileInfo
@@ -49,11 +49,28 @@ type FileSystemForLocal struct {
 }
 
 func (s *FileSystemForLocal) startServing() {
+	h := &webdav.Handler{
+		FileSystem: s.cfs,
+		LockSystem: webdav.NewMemLS(),
+	}
 	hs := &http.Server{
-		Handler: &webdav.Handler{
-			FileSystem: s.cfs,
-			LockSystem: webdav.NewMemLS(),
-		},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Paths are of the form /domain/remote/share/..., and MOVEs
+			// can only be carried out within a single share.
+			if rejectPartialPut(w, r) || rejectCrossShareMove(w, r, 3) {
+				return
+			}
+			// TODO(oxtoacart): RFC 4918 section 10.6 says a missing Overwrite
+			// header means T, but webdav.Handler treats it as F for MOVE (it
+			// does default to T for COPY). Defaulting it to T here would make
+			// webdav.Handler delete an existing destination before renaming,
+			// so only do that once MOVE within a share actually succeeds end
+			// to end; FileServer doesn't yet strip the share from Destination.
+			h.ServeHTTP(w, r)
+		}),
+		// Let webdav.Handler answer OPTIONS * with the DAV capability headers,
+		// rather than net/http's default handler, which sends none.
+		DisableGeneralOptionsHandler: true,
 	}
 	go func() {
 		err := hs.Serve(s.listener)
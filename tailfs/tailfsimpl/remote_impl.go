@@ -12,8 +12,10 @@
 	"net"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -27,15 +29,24 @@
 	"tailscale.com/types/logger"
 )
 
+const (
+	// defaultIdleConnTimeout bounds how long idle connections to file servers
+	// are kept open. Transports are otherwise only cleaned up when shares
+	// change or the filesystem is closed, so without this, idle connections
+	// could accumulate during long-running operation.
+	defaultIdleConnTimeout = 30 * time.Second
+)
+
 func NewFileSystemForRemote(logf logger.Logf) *FileSystemForRemote {
 	if logf == nil {
 		logf = log.Printf
 	}
 	fs := &FileSystemForRemote{
-		logf:        logf,
-		lockSystem:  webdav.NewMemLS(),
-		fileSystems: make(map[string]webdav.FileSystem),
-		userServers: make(map[string]*userServer),
+		logf:            logf,
+		lockSystem:      webdav.NewMemLS(),
+		idleConnTimeout: defaultIdleConnTimeout,
+		fileSystems:     make(map[string]webdav.FileSystem),
+		userServers:     make(map[string]*userServer),
 	}
 	return fs
 }
@@ -44,6 +55,9 @@ func NewFileSystemForRemote(logf logger.Logf) *FileSystemForRemote {
 type FileSystemForRemote struct {
 	logf       logger.Logf
 	lockSystem webdav.LockSystem
+	// idleConnTimeout is the IdleConnTimeout for share transports. It's only
+	// changed by tests.
+	idleConnTimeout time.Duration
 
 	// mu guards the below values. Acquire a write lock before updating any of
 	// them, acquire a read lock before reading any of them.
@@ -103,6 +117,7 @@ func (s *FileSystemForRemote) buildWebDAVFS(share *tailfs.Share) webdav.FileSyst
 		Logf: s.logf,
 		URL:  fmt.Sprintf("http://%v/%v", hex.EncodeToString([]byte(share.Name)), share.Name),
 		Transport: &http.Transport{
+			IdleConnTimeout: s.idleConnTimeout,
 			Dial: func(_, shareAddr string) (net.Conn, error) {
 				shareNameHex, _, err := net.SplitHostPort(shareAddr)
 				if err != nil {
@@ -172,6 +187,12 @@ func (s *FileSystemForRemote) ServeHTTPWithPerms(permissions tailfs.Permissions,
 		}
 	}
 
+	// Paths are of the form /share/..., and MOVEs can only be carried out
+	// within a single share.
+	if rejectPartialPut(w, r) || rejectCrossShareMove(w, r, 1) {
+		return
+	}
+
 	s.mu.RLock()
 	fileSystems := s.fileSystems
 	s.mu.RUnlock()
@@ -357,3 +378,40 @@ func (s *userServer) run(executable string) error {
 	"MOVE":      true,
 	"PROPPATCH": true,
 }
+
+// rejectPartialPut responds with 400 Bad Request and returns true if r is a PUT
+// with a Content-Range header. webdav.Handler ignores Content-Range and
+// truncates the target, which would silently replace the whole file with the
+// fragment, so per RFC 9110 section 14.4 we reject such requests instead.
+func rejectPartialPut(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "PUT" || r.Header.Get("Content-Range") == "" {
+		return false
+	}
+	http.Error(w, "partial PUT not supported", http.StatusBadRequest)
+	return true
+}
+
+// rejectCrossShareMove responds with 403 Forbidden and returns true if r is a
+// MOVE whose source and destination aren't both within the same share, where
+// the share is identified by the first shareDepth segments of the path.
+// compositefs can't rename across children, but webdav.Handler deletes an
+// existing destination (when Overwrite is T) before it attempts the rename,
+// so such a MOVE would destroy the destination and keep the source. Per RFC
+// 4918 section 9.9.4 we refuse it up front, without touching either resource.
+func rejectCrossShareMove(w http.ResponseWriter, r *http.Request, shareDepth int) bool {
+	if r.Method != "MOVE" {
+		return false
+	}
+	u, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || u.Path == "" {
+		// let webdav.Handler report the invalid Destination
+		return false
+	}
+	src := shared.CleanAndSplit(r.URL.Path)
+	dst := shared.CleanAndSplit(u.Path)
+	if len(src) > shareDepth && len(dst) > shareDepth && slices.Equal(src[:shareDepth], dst[:shareDepth]) {
+		return false
+	}
+	http.Error(w, "MOVE across shares not supported", http.StatusForbidden)
+	return true
+}
@@ -31,6 +31,11 @@ type StaticFileInfo struct {
 }
 
 // BirthTime implements webdav.BirthTimer
+//
+// TODO(oxtoacart): github.com/tailscale/xnet/webdav renders the creationdate
+// property of every webdav.BirthTimer with http.TimeFormat, but RFC 4918
+// section 15.1 requires the RFC 3339 date-time format. This affects all of
+// tailfs's BirthTimers, not just this one, and needs fixing in xnet/webdav.
 func (fi *StaticFileInfo) BirthTime(_ context.Context) (time.Time, error) {
 	return fi.BirthedTime, fi.BirthedTimeErr
 }
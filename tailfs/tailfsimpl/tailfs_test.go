@@ -4,6 +4,7 @@
 package tailfsimpl
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -11,9 +12,12 @@
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -258,6 +262,238 @@ func TestFileRewind(t *testing.T) {
 	}
 }
 
+func TestPartialPutRejected(t *testing.T) {
+	s := newSystem(t)
+	defer s.stop()
+
+	s.addRemote(remote1)
+	s.addShare(remote1, share11, tailfs.PermissionReadWrite)
+	s.writeFile("writing file to read/write remote should succeed", remote1, share11, file111, "0123456789", true)
+
+	tests := []struct {
+		label string
+		addr  net.Addr
+		path  string
+	}{
+		{
+			label: "local",
+			addr:  s.local.l.Addr(),
+			path:  "/" + pathTo(remote1, share11, file111),
+		},
+		{
+			label: "remote",
+			addr:  s.remotes[remote1].l.Addr(),
+			path:  "/" + path.Join(share11, file111),
+		},
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			u := &url.URL{Scheme: "http", Host: test.addr.String(), Path: test.path}
+			req, err := http.NewRequest("PUT", u.String(), strings.NewReader("xx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Range", "bytes 2-3/10")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+			if got, want := s.read(remote1, share11, file111), "0123456789"; got != want {
+				t.Errorf("got file contents %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCrossShareMoveRejected(t *testing.T) {
+	s := newSystem(t)
+	defer s.stop()
+
+	s.addRemote(remote1)
+	s.addShare(remote1, share11, tailfs.PermissionReadWrite)
+	s.addShare(remote1, share12, tailfs.PermissionReadWrite)
+	s.writeFile("writing file to read/write remote should succeed", remote1, share11, file111, "source", true)
+
+	dst := s.remotes[remote1].shares[share12]
+	existing := []string{"existing.txt", filepath.Join("existing", "file.txt")}
+
+	servers := []struct {
+		label  string
+		addr   net.Addr
+		pathTo func(share, name string) string
+	}{
+		{
+			label: "local",
+			addr:  s.local.l.Addr(),
+			pathTo: func(share, name string) string {
+				return "/" + pathTo(remote1, share, name)
+			},
+		},
+		{
+			label: "remote",
+			addr:  s.remotes[remote1].l.Addr(),
+			pathTo: func(share, name string) string {
+				return "/" + path.Join(share, name)
+			},
+		},
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for _, server := range servers {
+		for _, target := range []string{"existing.txt", "existing"} {
+			for _, overwrite := range []string{"T", "F"} {
+				t.Run(fmt.Sprintf("%s/%s/Overwrite=%s", server.label, target, overwrite), func(t *testing.T) {
+					if err := os.MkdirAll(filepath.Join(dst, "existing"), 0755); err != nil {
+						t.Fatal(err)
+					}
+					for _, name := range existing {
+						if err := os.WriteFile(filepath.Join(dst, name), []byte("existing"), 0644); err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					u := &url.URL{Scheme: "http", Host: server.addr.String(), Path: server.pathTo(share11, file111)}
+					req, err := http.NewRequest("MOVE", u.String(), nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					req.Header.Set("Destination", (&url.URL{Path: server.pathTo(share12, target)}).String())
+					req.Header.Set("Overwrite", overwrite)
+					resp, err := client.Do(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusForbidden {
+						t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusForbidden)
+					}
+					for _, name := range existing {
+						b, err := os.ReadFile(filepath.Join(dst, name))
+						if err != nil {
+							t.Errorf("destination lost: %v", err)
+						} else if got, want := string(b), "existing"; got != want {
+							t.Errorf("got %v contents %q, want %q", name, got, want)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCrossRemoteMoveKeepsBothSides(t *testing.T) {
+	s := newSystem(t)
+	defer s.stop()
+
+	s.addRemote(remote1)
+	s.addShare(remote1, share11, tailfs.PermissionReadWrite)
+	s.addRemote(remote2)
+	s.addShare(remote2, share12, tailfs.PermissionReadWrite)
+	s.writeFile("writing file to read/write remote should succeed", remote1, share11, file111, "source", true)
+	s.writeFile("writing file to read/write remote should succeed", remote2, share12, file111, "destination", true)
+
+	u := &url.URL{Scheme: "http", Host: s.local.l.Addr().String(), Path: "/" + pathTo(remote1, share11, file111)}
+	req, err := http.NewRequest("MOVE", u.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Destination", (&url.URL{Path: "/" + pathTo(remote2, share12, file111)}).String())
+	req.Header.Set("Overwrite", "T")
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	if got, want := s.read(remote1, share11, file111), "source"; got != want {
+		t.Errorf("got source contents %q, want %q", got, want)
+	}
+	if got, want := s.read(remote2, share12, file111), "destination"; got != want {
+		t.Errorf("got destination contents %q, want %q", got, want)
+	}
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	s := newSystem(t)
+	defer s.stop()
+
+	// httptest.NewRequest bypasses net/http's handling of OPTIONS *, so send
+	// the request over a real connection.
+	conn, err := net.Dial("tcp", s.local.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got, want := resp.Header.Get("DAV"), "1, 2"; got != want {
+		t.Errorf("got DAV %q, want %q", got, want)
+	}
+	if got := resp.Header.Get("Allow"); !strings.Contains(got, "OPTIONS") {
+		t.Errorf("got Allow %q, want it to include OPTIONS", got)
+	}
+}
+
+func TestIdleConnectionsClosed(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, share11), 0755); err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan struct{}, 1)
+	fileServer := httptest.NewUnstartedServer(&webdav.Handler{
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	})
+	fileServer.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
+	}
+	fileServer.Start()
+	defer fileServer.Close()
+
+	fs := NewFileSystemForRemote(t.Logf)
+	defer fs.Close()
+	fs.idleConnTimeout = 100 * time.Millisecond
+	fs.SetFileServerAddr(fileServer.Listener.Addr().String())
+	fs.SetShares(map[string]*tailfs.Share{share11: {Name: share11, Path: root}})
+
+	req := httptest.NewRequest("PROPFIND", "/"+url.PathEscape(share11), nil)
+	req.Header.Set("Depth", "0")
+	rec := httptest.NewRecorder()
+	fs.ServeHTTPWithPerms(tailfs.Permissions{share11: tailfs.PermissionReadOnly}, rec, req)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("idle connection to file server was never closed")
+	}
+}
+
 type local struct {
 	l  net.Listener
 	fs *FileSystemForLocal
@@ -162,11 +162,11 @@ func (f *readOnlyFile) statIfNecessary() error {
 		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), opTimeout)
 		defer cancel()
 
-		var err error
-		f.fi, err = f.client.Stat(ctxWithTimeout, f.name)
+		fi, err := f.client.Stat(ctxWithTimeout, f.name)
 		if err != nil {
 			return translateWebDAVError(err)
 		}
+		f.fi = withBirthTime(fi)
 	}
 
 	return nil
@@ -182,7 +182,15 @@ func (f *readOnlyFile) initReaderIfNecessary() error {
 
 	if f.ReadCloser == nil {
 		var err error
-		f.ReadCloser, err = f.client.ReadStreamOffset(context.Background(), f.name, f.position)
+		if f.position == 0 {
+			f.ReadCloser, err = f.client.ReadStream(context.Background(), f.name)
+		} else {
+			// We only get here after a Seek, which means that f.fi has been
+			// populated. Note, we don't use ReadStreamOffset because it fails
+			// when the server responds with 206 Partial Content.
+			offset := int64(f.position)
+			f.ReadCloser, err = f.client.ReadStreamRange(context.Background(), f.name, offset, f.fi.Size()-offset)
+		}
 		if err != nil {
 			return translateWebDAVError(err)
 		}
@@ -5,7 +5,7 @@
 
 import (
 	"io/fs"
-	"path/filepath"
+	"path"
 	"sync"
 	"time"
 
@@ -20,6 +20,10 @@ type statCache struct {
 	// mu guards the below values.
 	mu    sync.Mutex
 	cache *ttlcache.Cache[string, fs.FileInfo]
+	// generation is incremented on every invalidation. Fetches that started
+	// before an invalidation don't populate the cache, since their results
+	// may predate the write that caused the invalidation.
+	generation uint64
 }
 
 func newStatCache(ttl time.Duration) *statCache {
@@ -31,8 +35,10 @@ func newStatCache(ttl time.Duration) *statCache {
 }
 
 func (c *statCache) getOrFetch(name string, fetch func(string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	key := cleanKey(name)
 	c.mu.Lock()
-	item := c.cache.Get(name)
+	item := c.cache.Get(key)
+	generation := c.generation
 	c.mu.Unlock()
 
 	if item != nil {
@@ -42,20 +48,36 @@ func (c *statCache) getOrFetch(name string, fetch func(string) (fs.FileInfo, err
 	fi, err := fetch(name)
 	if err == nil {
 		c.mu.Lock()
-		c.cache.Set(name, fi, ttlcache.DefaultTTL)
+		if c.generation == generation {
+			c.cache.Set(key, fi, ttlcache.DefaultTTL)
+		}
 		c.mu.Unlock()
 	}
 
 	return fi, err
 }
 
-func (c *statCache) set(parentPath string, infos []fs.FileInfo) {
+// currentGeneration returns the cache's current invalidation generation, for
+// passing to set once the corresponding listing has been fetched.
+func (c *statCache) currentGeneration() uint64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.generation
+}
+
+// set caches the given infos for the children of parentPath, unless the cache
+// has been invalidated since generation was obtained from currentGeneration.
+func (c *statCache) set(parentPath string, infos []fs.FileInfo, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
 	for _, info := range infos {
-		path := filepath.Join(parentPath, filepath.Base(info.Name()))
-		c.cache.Set(path, info, ttlcache.DefaultTTL)
+		key := cleanKey(path.Join(parentPath, path.Base(info.Name())))
+		c.cache.Set(key, info, ttlcache.DefaultTTL)
 	}
 }
 
@@ -63,9 +85,18 @@ func (c *statCache) invalidate() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.generation++
 	c.cache.DeleteAll()
 }
 
+// cleanKey normalizes the given name so that equivalent paths like
+// "/dir/file", "dir/file" and "/dir//file/" share a single cache entry. Unlike
+// shared.CleanAndSplit, this keeps leading and trailing dots in names, so that
+// e.g. ".config" and "config" remain distinct.
+func cleanKey(name string) string {
+	return path.Clean("/" + name)
+}
+
 func (c *statCache) stop() {
 	c.cache.Stop()
 }
@@ -24,7 +24,7 @@ func TestStatCache(t *testing.T) {
 	}
 
 	// create file of size 1
-	filename := filepath.Join(dir, "thefile")
+	filename := filepath.ToSlash(filepath.Join(dir, "thefile"))
 	err = os.WriteFile(filename, []byte("1"), 0644)
 	if err != nil {
 		t.Fatal(err)
@@ -81,7 +81,7 @@ func TestStatCache(t *testing.T) {
 	}
 
 	// explicitly set the original FileInfo and make sure it's returned
-	c.set(dir, []fs.FileInfo{originalFI})
+	c.set(filepath.ToSlash(dir), []fs.FileInfo{originalFI}, c.currentGeneration())
 	fi, err = c.getOrFetch(filename, stat)
 	if err != nil {
 		t.Fatal(err)
@@ -102,3 +102,72 @@ func TestStatCache(t *testing.T) {
 
 	c.stop()
 }
+
+func TestStatCacheKeyNormalization(t *testing.T) {
+	// Make sure we don't leak goroutines
+	tstest.ResourceCheck(t)
+
+	fetches := 0
+	stat := func(name string) (fs.FileInfo, error) {
+		fetches++
+		return &shared.StaticFileInfo{Named: name, Dir: true}, nil
+	}
+	c := newStatCache(1 * time.Minute)
+	defer c.stop()
+
+	c.set("/domain/", []fs.FileInfo{&shared.StaticFileInfo{Named: "remote1", Dir: true}}, c.currentGeneration())
+	for _, name := range []string{"/domain/remote1", "/domain/remote1/", "/domain//remote1", "domain/./remote1"} {
+		if _, err := c.getOrFetch(name, stat); err != nil {
+			t.Fatal(err)
+		}
+		if fetches != 0 {
+			t.Errorf("%q: got %d fetches, want cache hit", name, fetches)
+		}
+	}
+
+	// a path that's only cached under its trailing-slash variant should still
+	// be found without the trailing slash
+	if _, err := c.getOrFetch("/domain/remote2/", stat); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.getOrFetch("/domain/remote2", stat); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want 1", fetches)
+	}
+}
+
+func TestStatCacheKeyKeepsDots(t *testing.T) {
+	// Make sure we don't leak goroutines
+	tstest.ResourceCheck(t)
+
+	stat := func(name string) (fs.FileInfo, error) {
+		t.Errorf("unexpected fetch of %q", name)
+		return nil, os.ErrNotExist
+	}
+	c := newStatCache(1 * time.Minute)
+	defer c.stop()
+
+	c.set("/dir", []fs.FileInfo{
+		&shared.StaticFileInfo{Named: ".x", Sized: 1},
+		&shared.StaticFileInfo{Named: "x", Sized: 6},
+		&shared.StaticFileInfo{Named: "x.", Sized: 3},
+	}, c.currentGeneration())
+	for _, test := range []struct {
+		name     string
+		wantSize int64
+	}{
+		{"/dir/.x", 1},
+		{"/dir/x", 6},
+		{"/dir/x.", 3},
+	} {
+		fi, err := c.getOrFetch(test.name, stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != test.wantSize {
+			t.Errorf("%q: got size %d, want %d", test.name, fi.Size(), test.wantSize)
+		}
+	}
+}
@@ -12,8 +12,10 @@
 	"io"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"time"
 
 	"github.com/tailscale/gowebdav"
@@ -71,6 +73,7 @@ func New(opts Options) webdav.FileSystem {
 		Client:    gowebdav.New(&gowebdav.Opts{URI: opts.URL, Transport: opts.Transport}),
 		statRoot:  opts.StatRoot,
 	}
+	wfs.Client.SetInterceptor(setContentType)
 	if opts.StatCacheTTL > 0 {
 		wfs.statCache = newStatCache(opts.StatCacheTTL)
 	}
@@ -87,10 +90,12 @@ func (wfs *webdavFS) Mkdir(ctx context.Context, name string, perm os.FileMode) e
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, opTimeout)
 	defer cancel()
 
-	if wfs.statCache != nil {
-		wfs.statCache.invalidate()
-	}
-	return translateWebDAVError(wfs.Client.Mkdir(ctxWithTimeout, name, perm))
+	wfs.invalidateStatCache()
+	err := wfs.Client.Mkdir(ctxWithTimeout, name, perm)
+	// Invalidate again, since paths may have been stat'ed while the MKCOL was
+	// in flight.
+	wfs.invalidateStatCache()
+	return translateWebDAVError(err)
 }
 
 // OpenFile implements webdav.FileSystem.
@@ -107,9 +112,7 @@ func (wfs *webdavFS) OpenFile(ctx context.Context, name string, flag int, perm o
 	defer cancel()
 
 	if hasFlag(flag, os.O_WRONLY) || hasFlag(flag, os.O_RDWR) {
-		if wfs.statCache != nil {
-			wfs.statCache.invalidate()
-		}
+		wfs.invalidateStatCache()
 
 		fi, err := wfs.Stat(ctxWithTimeout, name)
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -165,6 +168,10 @@ func (wfs *webdavFS) dirWithChildren(name string, fi fs.FileInfo) webdav.File {
 			ctxWithTimeout, cancel := context.WithTimeout(context.Background(), opTimeout)
 			defer cancel()
 
+			var generation uint64
+			if wfs.statCache != nil {
+				generation = wfs.statCache.currentGeneration()
+			}
 			dirInfos, err := wfs.Client.ReadDir(ctxWithTimeout, name)
 			if err != nil {
 				wfs.logf("encountered error reading children of '%v', returning empty list: %v", name, err)
@@ -172,8 +179,11 @@ func (wfs *webdavFS) dirWithChildren(name string, fi fs.FileInfo) webdav.File {
 				// will take that as an invitation to retry, hanging in the process.
 				return dirInfos, nil
 			}
+			for i, fi := range dirInfos {
+				dirInfos[i] = withBirthTime(fi)
+			}
 			if wfs.statCache != nil {
-				wfs.statCache.set(name, dirInfos)
+				wfs.statCache.set(name, dirInfos, generation)
 			}
 			return dirInfos, nil
 		},
@@ -185,10 +195,12 @@ func (wfs *webdavFS) RemoveAll(ctx context.Context, name string) error {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, opTimeout)
 	defer cancel()
 
-	if wfs.statCache != nil {
-		wfs.statCache.invalidate()
-	}
-	return wfs.Client.RemoveAll(ctxWithTimeout, name)
+	wfs.invalidateStatCache()
+	err := wfs.Client.RemoveAll(ctxWithTimeout, name)
+	// Invalidate again, since paths may have been stat'ed while the DELETE
+	// was in flight.
+	wfs.invalidateStatCache()
+	return err
 }
 
 // Rename implements webdav.FileSystem.
@@ -196,10 +208,19 @@ func (wfs *webdavFS) Rename(ctx context.Context, oldName, newName string) error
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, opTimeout)
 	defer cancel()
 
+	wfs.invalidateStatCache()
+	err := wfs.Client.Rename(ctxWithTimeout, oldName, newName, false)
+	// Invalidate again, since paths may have been stat'ed while the MOVE was
+	// in flight.
+	wfs.invalidateStatCache()
+	return err
+}
+
+// invalidateStatCache invalidates the StatCache, if there is one.
+func (wfs *webdavFS) invalidateStatCache() {
 	if wfs.statCache != nil {
 		wfs.statCache.invalidate()
 	}
-	return wfs.Client.Rename(ctxWithTimeout, oldName, newName, false)
 }
 
 // Stat implements webdav.FileSystem.
@@ -232,7 +253,57 @@ func (wfs *webdavFS) doStat(name string) (fs.FileInfo, error) {
 		return shared.ReadOnlyDirInfo(name, wfs.now()), nil
 	}
 	fi, err := wfs.Client.Stat(ctxWithTimeout, name)
-	return fi, translateWebDAVError(err)
+	if err != nil {
+		return nil, translateWebDAVError(err)
+	}
+	return withBirthTime(fi), nil
+}
+
+// withBirthTime makes sure that the given FileInfo reports a meaningful
+// BirthTime. Many WebDAV servers don't report a creationdate, in which case
+// gowebdav reports the Unix epoch as the BirthTime. In that case, we fall back
+// to the modification time so that clients don't see a creation date of
+// January 1st, 1970.
+func withBirthTime(fi fs.FileInfo) fs.FileInfo {
+	var f gowebdav.File
+	switch t := fi.(type) {
+	case gowebdav.File:
+		f = t
+	case *gowebdav.File:
+		f = *t
+	default:
+		return fi
+	}
+	bt, err := f.BirthTime(context.Background())
+	if err == nil && !bt.IsZero() && bt.Unix() != 0 {
+		return fi
+	}
+	return &modTimeBirthTimeFile{f}
+}
+
+// modTimeBirthTimeFile is a gowebdav.File that reports its modification time
+// as its BirthTime.
+type modTimeBirthTimeFile struct {
+	gowebdav.File
+}
+
+// BirthTime implements webdav.BirthTimer. See shared.StaticFileInfo.BirthTime
+// regarding how xnet/webdav formats the resulting creationdate.
+func (f *modTimeBirthTimeFile) BirthTime(_ context.Context) (time.Time, error) {
+	return f.ModTime(), nil
+}
+
+// setContentType sets the Content-Type of outbound PUT requests based on the
+// file's extension. The webdav.FileSystem interface doesn't give us access to
+// the Content-Type sent by the original client, so without this the remote
+// server would always receive uploads without one.
+func setContentType(method string, rq *http.Request) {
+	if method != http.MethodPut || rq.Header.Get("Content-Type") != "" {
+		return
+	}
+	if ct := mime.TypeByExtension(path.Ext(rq.URL.Path)); ct != "" {
+		rq.Header.Set("Content-Type", ct)
+	}
 }
 
 func translateWebDAVError(err error) error {
@@ -241,8 +312,15 @@ func translateWebDAVError(err error) error {
 	}
 	var se gowebdav.StatusError
 	if errors.As(err, &se) {
-		if se.Status == http.StatusNotFound {
+		switch se.Status {
+		case http.StatusNotFound:
 			return os.ErrNotExist
+		case http.StatusUnauthorized, http.StatusForbidden:
+			// Report remote auth failures as permission errors rather than
+			// unexpected ones. Note, the remote's auth challenge never reaches
+			// the client either way, since gowebdav consumes the response and
+			// xnet/webdav chooses the status returned to the client.
+			return os.ErrPermission
 		}
 	}
 	// Note, we intentionally don't wrap the error because we don't want
@@ -0,0 +1,419 @@
+// Copyright (c) Tailscale Inc & AUTHORS
+// SPDX-License-Identifier: BSD-3-Clause
+
+package webdavfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tailscale/xnet/webdav"
+)
+
+func TestRemoteAuthFailure(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a-secret.txt", "b-public.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newHandler(webdav.Dir(dir))
+	remote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a-secret.txt" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="remote"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+	wfs, close := newRemoteFS(t, remote, Options{})
+	defer close()
+
+	_, err := wfs.Stat(context.Background(), "/a-secret.txt")
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got error %v, want %v", err, os.ErrPermission)
+	}
+
+	// Since the remote's auth failure is reported as a permission error,
+	// xnet/webdav skips the entry rather than aborting the listing.
+	req := httptest.NewRequest("PROPFIND", "/", nil)
+	req.Header.Set("Depth", "1")
+	rec := httptest.NewRecorder()
+	newHandler(wfs).ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("listing exposed remote challenge %q", got)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "a-secret.txt") {
+		t.Errorf("listing should skip entry that failed auth:\n%s", body)
+	}
+	if !strings.Contains(body, "<D:href>/b-public.txt</D:href>") {
+		t.Errorf("listing should include entries after the one that failed auth:\n%s", body)
+	}
+
+	req = httptest.NewRequest("PROPFIND", "/a-secret.txt", nil)
+	req.Header.Set("Depth", "0")
+	rec = httptest.NewRecorder()
+	newHandler(wfs).ServeHTTP(rec, req)
+	// This pins existing xnet/webdav behavior rather than a status we chose:
+	// its PROPFIND answers any Stat error other than not-exist with 405.
+	// What matters here is that the remote's challenge isn't passed on.
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("PROPFIND exposed remote challenge %q", got)
+	}
+}
+
+func TestPutSetsContentType(t *testing.T) {
+	contentTypes := make(chan string, 1)
+	remote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PROPFIND":
+			w.WriteHeader(http.StatusNotFound)
+		case "PUT":
+			contentTypes <- r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	wfs, close := newRemoteFS(t, remote, Options{})
+	defer close()
+
+	f, err := wfs.OpenFile(context.Background(), "/file.html", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("<html></html>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "text/html; charset=utf-8"
+	if got := <-contentTypes; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+}
+
+func TestBirthTimeFallsBackToModTime(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// webdav.Dir doesn't implement webdav.BirthTimer, so this remote doesn't
+	// report a creationdate.
+	wfs, close := newRemoteFS(t, newHandler(webdav.Dir(dir)), Options{StatRoot: true})
+	defer close()
+
+	checkBirthTime := func(fi fs.FileInfo) {
+		t.Helper()
+		bt, ok := fi.(webdav.BirthTimer)
+		if !ok {
+			t.Fatalf("%v: FileInfo should be a BirthTimer", fi.Name())
+		}
+		birthTime, err := bt.BirthTime(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !birthTime.Equal(fi.ModTime()) {
+			t.Errorf("%v: got BirthTime %v, want ModTime %v", fi.Name(), birthTime, fi.ModTime())
+		}
+	}
+
+	ctx := context.Background()
+	fi, err := wfs.Stat(ctx, "/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBirthTime(fi)
+
+	// Seeking makes the file re-stat itself.
+	f, err := wfs.OpenFile(ctx, "/file.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	fi, err = f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBirthTime(fi)
+
+	d, err := wfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	infos, err := d.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("got %d children, want 1", len(infos))
+	}
+	checkBirthTime(infos[0])
+}
+
+func TestIfRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wfs, close := newRemoteFS(t, newHandler(webdav.Dir(dir)), Options{})
+	defer close()
+	h := newHandler(wfs)
+
+	get := func(ifRange string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/file.txt", nil)
+		if ifRange != "" {
+			req.Header.Set("Range", "bytes=5-")
+			req.Header.Set("If-Range", ifRange)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	etag := get("").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	tests := []struct {
+		label      string
+		ifRange    string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			label:      "matching If-Range",
+			ifRange:    etag,
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "56789",
+		},
+		{
+			label:      "non-matching If-Range",
+			ifRange:    `"stale"`,
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			rec := get(test.ifRange)
+			if rec.Code != test.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, test.wantStatus)
+			}
+			if got := rec.Body.String(); got != test.wantBody {
+				t.Errorf("got body %q, want %q", got, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestStatAfterWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wfs, close := newRemoteFS(t, newHandler(webdav.Dir(dir)), Options{StatCacheTTL: time.Hour})
+	defer close()
+
+	ctx := context.Background()
+	f, err := wfs.OpenFile(ctx, "/file.txt", os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	// Stat while the write is still in flight, which caches the old info.
+	if _, err := wfs.Stat(ctx, "/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("PROPFIND", "/file.txt", nil)
+	req.Header.Set("Depth", "0")
+	rec := httptest.NewRecorder()
+	newHandler(wfs).ServeHTTP(rec, req)
+	if want := "<D:getcontentlength>11</D:getcontentlength>"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("PROPFIND doesn't reflect completed write, want %q in:\n%s", want, rec.Body.String())
+	}
+}
+
+func TestStatDuringRemoval(t *testing.T) {
+	tests := []struct {
+		label  string
+		method string
+		remove func(wfs *webdavFS) error
+	}{
+		{
+			label:  "RemoveAll",
+			method: "DELETE",
+			remove: func(wfs *webdavFS) error {
+				return wfs.RemoveAll(context.Background(), "/file.txt")
+			},
+		},
+		{
+			label:  "Rename",
+			method: "MOVE",
+			remove: func(wfs *webdavFS) error {
+				return wfs.Rename(context.Background(), "/file.txt", "/renamed.txt")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			h := newHandler(webdav.Dir(dir))
+			started := make(chan struct{})
+			release := make(chan struct{})
+			remote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == test.method {
+					// Don't apply the removal until the file has been stat'ed.
+					close(started)
+					<-release
+				}
+				h.ServeHTTP(w, r)
+			})
+			wfs, closeFS := newRemoteFS(t, remote, Options{StatCacheTTL: time.Hour})
+			defer closeFS()
+
+			removeDone := make(chan error, 1)
+			go func() {
+				removeDone <- test.remove(wfs)
+			}()
+			<-started
+
+			// Stat while the removal is still in flight, which caches the old
+			// info.
+			ctx := context.Background()
+			if _, err := wfs.Stat(ctx, "/file.txt"); err != nil {
+				t.Fatal(err)
+			}
+			close(release)
+			if err := <-removeDone; err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := wfs.Stat(ctx, "/file.txt")
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestListingDuringWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(webdav.Dir(dir))
+	listed := make(chan struct{})
+	release := make(chan struct{})
+	remote := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" || r.Header.Get("Depth") != "1" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// Produce the listing now, but don't respond until the write is done.
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		close(listed)
+		<-release
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+	wfs, closeFS := newRemoteFS(t, remote, Options{StatCacheTTL: time.Hour})
+	defer closeFS()
+
+	ctx := context.Background()
+	d, err := wfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	readDirDone := make(chan error, 1)
+	go func() {
+		_, err := d.Readdir(0)
+		readDirDone <- err
+	}()
+	<-listed
+
+	f, err := wfs.OpenFile(ctx, "/file.txt", os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the pre-write listing complete, which must not repopulate the cache.
+	close(release)
+	if err := <-readDirDone; err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := wfs.Stat(ctx, "/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 11 {
+		t.Errorf("got size %d, want 11", fi.Size())
+	}
+}
+
+// newHandler returns a webdav.Handler that serves fs using an in-memory lock
+// system.
+func newHandler(fs webdav.FileSystem) *webdav.Handler {
+	return &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// newRemoteFS starts a test server using the given remote handler and returns
+// a webdavFS connected to it using opts, along with a function that closes
+// both. Logf, URL and Transport in opts are filled in automatically.
+func newRemoteFS(t *testing.T, remote http.Handler, opts Options) (*webdavFS, func()) {
+	srv := httptest.NewServer(remote)
+	opts.Logf = t.Logf
+	opts.URL = srv.URL
+	opts.Transport = srv.Client().Transport
+	wfs := New(opts).(*webdavFS)
+	return wfs, func() {
+		defer srv.Close()
+		wfs.Close()
+	}
+}
@@ -82,6 +82,9 @@ func (f *writeOnlyFile) Write(p []byte) (int, error) {
 func (f *writeOnlyFile) Close() error {
 	err := f.WriteCloser.Close()
 	writeErr := <-f.finalError
+	// The file may have been stat'ed while the write was in flight, so
+	// invalidate again now that the write has completed.
+	f.fs.invalidateStatCache()
 	if writeErr != nil {
 		return writeErr
 	}